@@ -0,0 +1,312 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// AdditionType identifies one of the small, individually-fetchable pieces of
+// a chart that callers frequently want without pulling (and unpacking) the
+// whole chart tarball.
+type AdditionType string
+
+const (
+	// AdditionValues is the chart's values.yaml.
+	AdditionValues AdditionType = "values"
+	// AdditionReadme is the chart's README.md.
+	AdditionReadme AdditionType = "readme"
+	// AdditionDependencies is the chart's dependency tree, derived from its
+	// Chart.yaml/requirements.yaml plus any vendored charts/*/Chart.yaml.
+	AdditionDependencies AdditionType = "dependencies"
+)
+
+const (
+	// HelmChartConfigMediaType is the media type of an OCI chart's config
+	// layer, a small JSON document derived from Chart.yaml.
+	HelmChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+	// HelmChartContentLayerMediaType is the media type of the layer holding
+	// the gzipped chart tarball.
+	HelmChartContentLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	// legacyHelmChartContentLayerMediaType is the media type charts pushed by
+	// older Helm versions used for the same layer.
+	legacyHelmChartContentLayerMediaType = "application/tar+gzip"
+)
+
+// additionMembers is the set of tar members GetAddition ever needs, across
+// every AdditionType. Only these members are read out of the decompressed
+// stream; every other entry is skipped without being buffered.
+var additionMembers = map[string]bool{
+	"values.yaml":       true,
+	"README.md":         true,
+	"Chart.yaml":        true,
+	"requirements.yaml": true,
+}
+
+// dependencyNode describes one entry in a chart's dependency tree, including
+// the subcharts vendored under charts/ that were discovered in the tarball
+// rather than declared.
+type dependencyNode struct {
+	Name         string           `json:"name"`
+	Version      string           `json:"version,omitempty"`
+	Repository   string           `json:"repository,omitempty"`
+	Dependencies []dependencyNode `json:"dependencies,omitempty"`
+}
+
+// GetAddition fetches just the layer(s) of the OCI artifact referenced by ref
+// needed to satisfy additionType, without pulling or unpacking the full chart
+// tarball, and returns the parsed content as a string (raw YAML/markdown for
+// AdditionValues/AdditionReadme, or a marshaled dependency tree for
+// AdditionDependencies).
+func (c *Client) GetAddition(ref string, additionType AdditionType) (string, error) {
+	ctx := context.Background()
+
+	_, desc, err := c.resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not resolve %s", ref)
+	}
+
+	fetcher, err := c.resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create fetcher for %s", ref)
+	}
+
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return "", err
+	}
+
+	var chartLayer *ocispec.Descriptor
+	for i, layer := range manifest.Layers {
+		if layer.MediaType == HelmChartContentLayerMediaType || layer.MediaType == legacyHelmChartContentLayerMediaType {
+			chartLayer = &manifest.Layers[i]
+			break
+		}
+	}
+	if chartLayer == nil {
+		return "", errors.Errorf("no chart content layer found in manifest for %s", ref)
+	}
+
+	rc, err := fetcher.Fetch(ctx, *chartLayer)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not fetch chart content layer for %s", ref)
+	}
+	defer rc.Close()
+
+	members, err := extractMembers(rc, additionMembers)
+	if err != nil {
+		return "", err
+	}
+
+	return renderAddition(members, additionType)
+}
+
+// fetchManifest fetches and decodes the OCI manifest for the already-resolved
+// desc.
+func fetchManifest(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch manifest")
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read manifest")
+	}
+
+	var manifest ocispec.Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Wrap(err, "could not decode manifest")
+	}
+	return &manifest, nil
+}
+
+// extractMembers stream-decompresses a gzipped chart tarball, returning the
+// raw content of only the tar entries whose base name is in want. Charts
+// vendored under charts/*/Chart.yaml are also captured, keyed by their full
+// in-tar path, so the dependency tree can include them.
+func extractMembers(r io.Reader, want map[string]bool) (map[string][]byte, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open chart content layer as gzip")
+	}
+	defer gzr.Close()
+
+	members := map[string][]byte{}
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read chart content layer tar")
+		}
+
+		name := path.Base(hdr.Name)
+		isVendoredChartYAML := name == "Chart.yaml" && path.Base(path.Dir(path.Dir(hdr.Name))) == "charts"
+		if !want[name] && !isVendoredChartYAML {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read %s", hdr.Name)
+		}
+		members[hdr.Name] = data
+	}
+	return members, nil
+}
+
+// renderAddition builds the response for additionType out of the tar members
+// captured by extractMembers.
+func renderAddition(members map[string][]byte, additionType AdditionType) (string, error) {
+	switch additionType {
+	case AdditionValues:
+		data, ok := shallowestMatch(members, "values.yaml")
+		if !ok {
+			return "", nil
+		}
+		return string(data), nil
+
+	case AdditionReadme:
+		data, ok := shallowestMatch(members, "README.md")
+		if !ok {
+			return "", nil
+		}
+		return string(data), nil
+
+	case AdditionDependencies:
+		tree, err := buildDependencyTree(members)
+		if err != nil {
+			return "", err
+		}
+		out, err := yaml.Marshal(tree)
+		if err != nil {
+			return "", errors.Wrap(err, "could not marshal dependency tree")
+		}
+		return string(out), nil
+
+	default:
+		return "", errors.Errorf("unknown addition type %q", additionType)
+	}
+}
+
+// buildDependencyTree derives a chart's dependency tree from its root
+// Chart.yaml (falling back to requirements.yaml for charts using the old
+// requirements.yaml convention) plus whatever subcharts were vendored under
+// charts/ in the tarball.
+func buildDependencyTree(members map[string][]byte) (*dependencyNode, error) {
+	var root struct {
+		Name         string `json:"name"`
+		Version      string `json:"version"`
+		Dependencies []struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			Repository string `json:"repository"`
+		} `json:"dependencies"`
+	}
+
+	chartYAML, ok := shallowestMatch(members, "Chart.yaml")
+	if !ok {
+		return nil, errors.New("Chart.yaml not found in chart content layer")
+	}
+	if err := yaml.Unmarshal(chartYAML, &root); err != nil {
+		return nil, errors.Wrap(err, "could not parse Chart.yaml")
+	}
+
+	if len(root.Dependencies) == 0 {
+		if reqYAML, ok := shallowestMatch(members, "requirements.yaml"); ok {
+			if err := yaml.Unmarshal(reqYAML, &root); err != nil {
+				return nil, errors.Wrap(err, "could not parse requirements.yaml")
+			}
+		}
+	}
+
+	tree := &dependencyNode{Name: root.Name, Version: root.Version}
+	declared := map[string]bool{}
+	for _, dep := range root.Dependencies {
+		tree.Dependencies = append(tree.Dependencies, dependencyNode{
+			Name:       dep.Name,
+			Version:    dep.Version,
+			Repository: dep.Repository,
+		})
+		declared[dep.Name] = true
+	}
+
+	// Vendored subcharts augment (rather than replace) declared dependencies:
+	// only those whose name is not declared are added, and a declared
+	// dependency keeps its declared version even when a vendored copy exists.
+	for name, data := range members {
+		if !isVendoredChartYAMLPath(name) {
+			continue
+		}
+		var sub struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err := yaml.Unmarshal(data, &sub); err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", name)
+		}
+		if declared[sub.Name] {
+			continue
+		}
+		tree.Dependencies = append(tree.Dependencies, dependencyNode{Name: sub.Name, Version: sub.Version})
+	}
+
+	return tree, nil
+}
+
+func isVendoredChartYAMLPath(name string) bool {
+	return path.Base(name) == "Chart.yaml" && path.Base(path.Dir(path.Dir(name))) == "charts"
+}
+
+// shallowestMatch returns the member named base with the fewest path
+// segments, i.e. the top-level chart's copy rather than a vendored
+// charts/<dep>/<base> one. Iteration order over a map is random, so picking
+// by depth (instead of "whichever comes first") is what makes this
+// deterministic.
+func shallowestMatch(members map[string][]byte, base string) ([]byte, bool) {
+	var best []byte
+	bestDepth := -1
+	found := false
+
+	for name, data := range members {
+		if path.Base(name) != base {
+			continue
+		}
+		depth := strings.Count(name, "/")
+		if !found || depth < bestDepth {
+			best, bestDepth, found = data, depth, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,208 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func buildChartTarGz(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractMembers(t *testing.T) {
+	tgz := buildChartTarGz(t, map[string]string{
+		"mychart/Chart.yaml":                "name: mychart\nversion: 0.1.0\n",
+		"mychart/values.yaml":               "replicas: 1\n",
+		"mychart/README.md":                 "# mychart\n",
+		"mychart/templates/deployment.yaml": "kind: Deployment\n",
+		"mychart/charts/dep/Chart.yaml":     "name: dep\nversion: 1.2.3\n",
+	})
+
+	members, err := extractMembers(tgz, additionMembers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := members["mychart/templates/deployment.yaml"]; ok {
+		t.Error("expected templates not in additionMembers to be skipped")
+	}
+	if string(members["mychart/values.yaml"]) != "replicas: 1\n" {
+		t.Errorf("unexpected values.yaml content: %q", members["mychart/values.yaml"])
+	}
+	if _, ok := members["mychart/charts/dep/Chart.yaml"]; !ok {
+		t.Error("expected vendored subchart Chart.yaml to be captured")
+	}
+}
+
+func TestRenderAdditionValuesAndReadme(t *testing.T) {
+	members := map[string][]byte{
+		"mychart/values.yaml": []byte("replicas: 1\n"),
+		"mychart/README.md":   []byte("# mychart\n"),
+	}
+
+	values, err := renderAddition(members, AdditionValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values != "replicas: 1\n" {
+		t.Errorf("unexpected values: %q", values)
+	}
+
+	readme, err := renderAddition(members, AdditionReadme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readme != "# mychart\n" {
+		t.Errorf("unexpected readme: %q", readme)
+	}
+}
+
+// TestRenderAdditionValuesAndReadmePicksTopLevelCopy guards against picking a
+// vendored subchart's values.yaml/README.md instead of the top-level chart's:
+// extractMembers also captures charts/<dep>/values.yaml and
+// charts/<dep>/README.md since they share the base name the "want" filter
+// matches on, so renderAddition must prefer the shallowest copy regardless of
+// map iteration order.
+func TestRenderAdditionValuesAndReadmePicksTopLevelCopy(t *testing.T) {
+	members := map[string][]byte{
+		"mychart/values.yaml":            []byte("replicas: 1\n"),
+		"mychart/charts/dep/values.yaml": []byte("replicas: 99\n"),
+		"mychart/README.md":              []byte("# mychart\n"),
+		"mychart/charts/dep/README.md":   []byte("# dep\n"),
+	}
+
+	for i := 0; i < 20; i++ {
+		values, err := renderAddition(members, AdditionValues)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values != "replicas: 1\n" {
+			t.Fatalf("expected top-level values.yaml, got %q", values)
+		}
+
+		readme, err := renderAddition(members, AdditionReadme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if readme != "# mychart\n" {
+			t.Fatalf("expected top-level README.md, got %q", readme)
+		}
+	}
+}
+
+func TestBuildDependencyTree(t *testing.T) {
+	members := map[string][]byte{
+		"mychart/Chart.yaml": []byte(`
+name: mychart
+version: 0.1.0
+dependencies:
+- name: declared
+  version: 2.0.0
+  repository: https://example.com/charts
+`),
+		"mychart/charts/vendored/Chart.yaml": []byte("name: vendored\nversion: 3.0.0\n"),
+	}
+
+	tree, err := buildDependencyTree(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Name != "mychart" || tree.Version != "0.1.0" {
+		t.Errorf("unexpected root: %+v", tree)
+	}
+	if len(tree.Dependencies) != 2 {
+		t.Fatalf("expected 2 dependencies, got %d: %+v", len(tree.Dependencies), tree.Dependencies)
+	}
+
+	var names []string
+	for _, dep := range tree.Dependencies {
+		names = append(names, dep.Name)
+	}
+	if !strings.Contains(strings.Join(names, ","), "declared") || !strings.Contains(strings.Join(names, ","), "vendored") {
+		t.Errorf("expected both declared and vendored dependencies, got %v", names)
+	}
+}
+
+// TestBuildDependencyTreePicksTopLevelChartYAML guards against picking a
+// vendored subchart's Chart.yaml as the tree's root: the root must always be
+// the shallowest Chart.yaml in the tarball, regardless of map iteration order.
+func TestBuildDependencyTreePicksTopLevelChartYAML(t *testing.T) {
+	members := map[string][]byte{
+		"mychart/Chart.yaml":                 []byte("name: mychart\nversion: 0.1.0\n"),
+		"mychart/charts/vendored/Chart.yaml": []byte("name: vendored\nversion: 3.0.0\n"),
+	}
+
+	for i := 0; i < 20; i++ {
+		tree, err := buildDependencyTree(members)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.Name != "mychart" || tree.Version != "0.1.0" {
+			t.Fatalf("expected top-level chart as root, got %+v", tree)
+		}
+	}
+}
+
+// TestBuildDependencyTreeRequirementsYAMLFallback covers the requirements.yaml
+// fallback against a realistic tarball where members are keyed by their full
+// in-tar path (e.g. "mychart/requirements.yaml"), not a bare "requirements.yaml".
+func TestBuildDependencyTreeRequirementsYAMLFallback(t *testing.T) {
+	members := map[string][]byte{
+		"mychart/Chart.yaml": []byte("name: mychart\nversion: 0.1.0\n"),
+		"mychart/requirements.yaml": []byte(`
+dependencies:
+- name: fromrequirements
+  version: 1.0.0
+  repository: https://example.com/charts
+`),
+	}
+
+	tree, err := buildDependencyTree(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Dependencies) != 1 || tree.Dependencies[0].Name != "fromrequirements" {
+		t.Fatalf("expected requirements.yaml dependency to be picked up, got %+v", tree.Dependencies)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeCredentialProvider struct {
+	username, password, token string
+	err                       error
+}
+
+func (f *fakeCredentialProvider) Resolve(ref string) (string, string, string, error) {
+	return f.username, f.password, f.token, f.err
+}
+
+func TestChainedCredentialProviderFirstMatchWins(t *testing.T) {
+	chain := &ChainedCredentialProvider{
+		Providers: []CredentialProvider{
+			&fakeCredentialProvider{},
+			&fakeCredentialProvider{username: "u", password: "p"},
+			&fakeCredentialProvider{token: "should-not-be-reached"},
+		},
+	}
+
+	username, password, token, err := chain.Resolve("example.com/chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "u" || password != "p" || token != "" {
+		t.Errorf("expected credentials from second provider, got %q %q %q", username, password, token)
+	}
+}
+
+func TestChainedCredentialProviderSkipsErrors(t *testing.T) {
+	chain := &ChainedCredentialProvider{
+		Providers: []CredentialProvider{
+			&fakeCredentialProvider{err: errors.New("boom")},
+			&fakeCredentialProvider{token: "tok"},
+		},
+	}
+
+	_, _, token, err := chain.Resolve("example.com/chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "tok" {
+		t.Errorf("expected fallback provider's token, got %q", token)
+	}
+}
+
+func TestChainedCredentialProviderAllEmptyReturnsLastError(t *testing.T) {
+	boom := errors.New("boom")
+	chain := &ChainedCredentialProvider{
+		Providers: []CredentialProvider{
+			&fakeCredentialProvider{},
+			&fakeCredentialProvider{err: boom},
+		},
+	}
+
+	_, _, _, err := chain.Resolve("example.com/chart")
+	if err != boom {
+		t.Errorf("expected last error to surface, got %v", err)
+	}
+}
+
+func TestHostFromRef(t *testing.T) {
+	cases := map[string]string{
+		"example.com/charts/mychart:1.0.0": "example.com",
+		"example.com":                      "example.com",
+	}
+	for ref, want := range cases {
+		if got := hostFromRef(ref); got != want {
+			t.Errorf("hostFromRef(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestCloudTokenCredentialProviderResolve(t *testing.T) {
+	provider := &CloudTokenCredentialProvider{
+		Name: "ecr",
+		Fetch: func(ref string) (string, string, error) {
+			if ref != "example.com/chart" {
+				t.Errorf("unexpected ref passed to Fetch: %q", ref)
+			}
+			return "AWS", "token", nil
+		},
+	}
+
+	username, password, token, err := provider.Resolve("example.com/chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "AWS" || password != "token" || token != "" {
+		t.Errorf("unexpected credentials: %q %q %q", username, password, token)
+	}
+}
+
+func TestCloudTokenCredentialProviderResolveFetchError(t *testing.T) {
+	boom := errors.New("boom")
+	provider := &CloudTokenCredentialProvider{
+		Name: "ecr",
+		Fetch: func(ref string) (string, string, error) {
+			return "", "", boom
+		},
+	}
+
+	if _, _, _, err := provider.Resolve("example.com/chart"); err == nil {
+		t.Fatal("expected an error when Fetch fails")
+	}
+}
+
+func TestCloudTokenCredentialProviderResolveNilFetch(t *testing.T) {
+	provider := &CloudTokenCredentialProvider{Name: "ecr"}
+
+	username, password, token, err := provider.Resolve("example.com/chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" || password != "" || token != "" {
+		t.Errorf("expected no credentials with a nil Fetch, got %q %q %q", username, password, token)
+	}
+}
+
+func dockerConfigJSONSecret(name, host, username, password string) *corev1.Secret {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	data := []byte(`{"auths":{"` + host + `":{"auth":"` + auth + `"}}}`)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: data,
+		},
+	}
+}
+
+func TestKubernetesImagePullSecretsProviderResolve(t *testing.T) {
+	secret := dockerConfigJSONSecret("regcred", "example.com", "myuser", "mypass")
+	provider := &KubernetesImagePullSecretsProvider{
+		Clientset:   fakeclientset.NewSimpleClientset(secret),
+		Namespace:   "default",
+		SecretNames: []string{"regcred"},
+	}
+
+	username, password, token, err := provider.Resolve("example.com/charts/mychart:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "myuser" || password != "mypass" || token != "" {
+		t.Errorf("unexpected credentials: %q %q %q", username, password, token)
+	}
+}
+
+func TestKubernetesImagePullSecretsProviderResolveNoMatchingHost(t *testing.T) {
+	secret := dockerConfigJSONSecret("regcred", "other.com", "myuser", "mypass")
+	provider := &KubernetesImagePullSecretsProvider{
+		Clientset:   fakeclientset.NewSimpleClientset(secret),
+		Namespace:   "default",
+		SecretNames: []string{"regcred"},
+	}
+
+	username, password, token, err := provider.Resolve("example.com/charts/mychart:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" || password != "" || token != "" {
+		t.Errorf("expected no credentials for a non-matching host, got %q %q %q", username, password, token)
+	}
+}
+
+func TestKubernetesImagePullSecretsProviderResolveMissingSecret(t *testing.T) {
+	provider := &KubernetesImagePullSecretsProvider{
+		Clientset:   fakeclientset.NewSimpleClientset(),
+		Namespace:   "default",
+		SecretNames: []string{"missing"},
+	}
+
+	_, _, _, err := provider.Resolve("example.com/charts/mychart:1.0.0")
+	if err == nil {
+		t.Fatal("expected an error when no SecretNames resolve")
+	}
+}
+
+func TestNewResolverFromCredentialProvider(t *testing.T) {
+	resolver := NewResolverFromCredentialProvider(&fakeCredentialProvider{username: "u", password: "p"})
+	if resolver == nil {
+		t.Fatal("expected a non-nil resolver")
+	}
+}
+
+func TestDockerConfigJSONAuth(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("myuser:mypass"))
+	data := []byte(`{"auths":{"example.com":{"auth":"` + auth + `"}}}`)
+
+	username, password, ok := dockerConfigJSONAuth(data, "example.com")
+	if !ok {
+		t.Fatal("expected a match for example.com")
+	}
+	if username != "myuser" || password != "mypass" {
+		t.Errorf("unexpected credentials: %q %q", username, password)
+	}
+
+	if _, _, ok := dockerConfigJSONAuth(data, "other.com"); ok {
+		t.Error("expected no match for other.com")
+	}
+}
@@ -16,13 +16,12 @@ limitations under the License.
 package action
 
 import (
-	"context"
 	"flag"
 	"io/ioutil"
+	"path"
 	"path/filepath"
 	"testing"
 
-	dockerauth "github.com/deislabs/oras/pkg/auth/docker"
 	fakeclientset "k8s.io/client-go/kubernetes/fake"
 
 	"helm.sh/helm/v3/internal/experimental/registry"
@@ -37,19 +36,15 @@ import (
 
 var verbose = flag.Bool("test.log", false, "enable test logging")
 
-func actionConfigFixture(t *testing.T) *Configuration {
+// actionConfigFixture builds a Configuration wired up for tests, building its
+// registry client with NewRegistryClient. If providers is non-empty, the
+// fixture chains those
+// registry.CredentialProvider implementations instead of authenticating via
+// the on-disk Docker config, so tests can inject fakes (e.g. for
+// imagePullSecrets or cloud token helpers) without touching it.
+func actionConfigFixture(t *testing.T, providers ...registry.CredentialProvider) *Configuration {
 	t.Helper()
 
-	client, err := dockerauth.NewClient()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	resolver, err := client.Resolver(context.Background())
-	if err != nil {
-		t.Fatal(err)
-	}
-
 	tdir, err := ioutil.TempDir("", "helm-action-test")
 	if err != nil {
 		t.Fatal(err)
@@ -63,15 +58,7 @@ func actionConfigFixture(t *testing.T) *Configuration {
 		t.Fatal(err)
 	}
 
-	registryClient, err := registry.NewClient(
-		registry.ClientOptAuthorizer(&registry.Authorizer{
-			Client: client,
-		}),
-		registry.ClientOptResolver(&registry.Resolver{
-			Resolver: resolver,
-		}),
-		registry.ClientOptCache(cache),
-	)
+	registryClient, err := NewRegistryClient(cache, providers...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -205,6 +192,21 @@ func withNotes(notes string) chartOption {
 	}
 }
 
+// withProfileOverlay stashes the given files on the chart's Files list under
+// a "profile/" prefix, so a test can build both a chart and its companion
+// profile overlay archive from a single buildChart call, then hand the
+// "profile/" entries to LoadProfileOverlay.
+func withProfileOverlay(files map[string]string) chartOption {
+	return func(opts *chartOptions) {
+		for name, data := range files {
+			opts.Files = append(opts.Files, &chart.File{
+				Name: path.Join("profile", name),
+				Data: []byte(data),
+			})
+		}
+	}
+}
+
 func withDependency(dependencyOpts ...chartOption) chartOption {
 	return func(opts *chartOptions) {
 		opts.AddDependency(buildChart(dependencyOpts...))
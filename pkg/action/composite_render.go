@@ -0,0 +1,250 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// CompositeApp is one chart, with its own values, participating in a
+// RenderComposite render alongside the rest of the composite application.
+type CompositeApp struct {
+	// Name identifies the app within the composite and namespaces both its
+	// rendered output and any profile overlay entries meant for it
+	// (<Name>/templates/<path>, <Name>/values.yaml).
+	Name   string
+	Chart  *chart.Chart
+	Values map[string]interface{}
+}
+
+// ProfileOverlay is a "deployment intent" style archive that customizes a
+// composite application's rendered output: replacing an app's values.yaml,
+// adding extra manifests, or patching already-rendered resources by name.
+type ProfileOverlay struct {
+	// Files is keyed by the same "<app-name>/..." paths used for
+	// CompositeApp.Name, e.g. "frontend/templates/ingress.yaml" or
+	// "frontend/values.yaml".
+	Files map[string][]byte
+}
+
+// LoadProfileOverlay tar-walks r, collecting every regular file into a
+// ProfileOverlay keyed by its path within the archive. Directories, links and
+// other non-regular entries are skipped.
+func LoadProfileOverlay(r io.Reader) (*ProfileOverlay, error) {
+	overlay := &ProfileOverlay{Files: map[string][]byte{}}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read profile overlay archive")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data := make([]byte, hdr.Size)
+		if _, err := io.ReadFull(tr, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s from profile overlay", hdr.Name)
+		}
+		overlay.Files[path.Clean(hdr.Name)] = data
+	}
+	return overlay, nil
+}
+
+// valuesOverride returns the overlay's replacement values for app, if any.
+func (p *ProfileOverlay) valuesOverride(appName string) (map[string]interface{}, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	data, ok := p.Files[path.Join(appName, "values.yaml")]
+	if !ok {
+		return nil, false, nil
+	}
+
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vals); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to parse values.yaml override for %s", appName)
+	}
+	return vals, true, nil
+}
+
+// templateOverrides returns the overlay entries that replace or add rendered
+// template output for app, keyed by the chart-relative path
+// ("templates/<path>"); Run prefixes the chart name to match the keys
+// engine.Render returns.
+func (p *ProfileOverlay) templateOverrides(appName string) map[string][]byte {
+	if p == nil {
+		return nil
+	}
+	prefix := appName + "/templates/"
+	overrides := map[string][]byte{}
+	for name, data := range p.Files {
+		if rel := strings.TrimPrefix(name, prefix); rel != name {
+			overrides["templates/"+rel] = data
+		}
+	}
+	return overrides
+}
+
+// patchesByResourceName returns the overlay entries under <app-name>/patches/
+// that replace an already-rendered resource in full, keyed by the resource
+// name the patch targets (the file's base name without extension).
+func (p *ProfileOverlay) patchesByResourceName(appName string) map[string][]byte {
+	if p == nil {
+		return nil
+	}
+	prefix := appName + "/patches/"
+	patches := map[string][]byte{}
+	for name, data := range p.Files {
+		if rel := strings.TrimPrefix(name, prefix); rel != name {
+			resourceName := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+			patches[resourceName] = data
+		}
+	}
+	return patches
+}
+
+// RenderComposite renders a set of charts as a single composite application,
+// with a profile overlay able to override per-app values, inject or replace
+// rendered manifests, and patch rendered resources by name, before the final
+// manifest stream is sorted the same way a single-chart install would be.
+type RenderComposite struct {
+	cfg *Configuration
+
+	// Namespace seeds the release namespace each app is rendered against.
+	Namespace string
+}
+
+// NewRenderComposite creates a new RenderComposite action.
+func NewRenderComposite(cfg *Configuration) *RenderComposite {
+	return &RenderComposite{cfg: cfg}
+}
+
+// Run renders every app in apps, applies overlay on top, and returns the
+// combined, sorted manifest stream.
+func (r *RenderComposite) Run(apps []*CompositeApp, overlay *ProfileOverlay) (string, error) {
+	caps := r.cfg.Capabilities
+	if caps == nil {
+		caps = chartutil.DefaultCapabilities
+	}
+
+	combined := map[string]string{}
+
+	for _, app := range apps {
+		vals := app.Values
+		if override, ok, err := overlay.valuesOverride(app.Name); err != nil {
+			return "", err
+		} else if ok {
+			// override takes precedence, but any base key it doesn't mention
+			// must survive, so coalesce rather than replace wholesale.
+			vals = chartutil.CoalesceTables(override, app.Values)
+		}
+
+		options := chartutil.ReleaseOptions{Name: app.Name, Namespace: r.Namespace}
+		valuesToRender, err := chartutil.ToRenderValues(app.Chart, vals, options, caps)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to compute values for %s", app.Name)
+		}
+
+		rendered, err := engine.Render(app.Chart, valuesToRender)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to render %s", app.Name)
+		}
+
+		for templatePath, override := range overlay.templateOverrides(app.Name) {
+			rendered[path.Join(app.Chart.Name(), templatePath)] = string(override)
+		}
+
+		patches := overlay.patchesByResourceName(app.Name)
+
+		for templatePath, content := range rendered {
+			if strings.HasSuffix(templatePath, notesFileSuffix) {
+				continue
+			}
+			if strings.HasPrefix(path.Base(templatePath), "_") {
+				continue
+			}
+			for _, manifest := range releaseutil.SplitManifests(content) {
+				manifest = applyResourcePatches(manifest, patches)
+				if strings.TrimSpace(manifest) == "" {
+					continue
+				}
+				key := fmt.Sprintf("%s/%s", app.Name, templatePath)
+				combined[key] = appendManifest(combined[key], manifest)
+			}
+		}
+	}
+
+	hooks, generic, err := releaseutil.SortManifests(combined, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sort composite manifests")
+	}
+
+	var docs []string
+	for _, hook := range hooks {
+		docs = append(docs, hook.Manifest)
+	}
+	for _, manifest := range generic {
+		docs = append(docs, manifest.Content)
+	}
+
+	return strings.Join(docs, "\n---\n"), nil
+}
+
+// applyResourcePatches replaces manifest wholesale if its metadata.name
+// matches a patch in patches, otherwise returns it unchanged.
+func applyResourcePatches(manifest string, patches map[string][]byte) string {
+	if len(patches) == 0 {
+		return manifest
+	}
+
+	var obj struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		return manifest
+	}
+	if patch, ok := patches[obj.Metadata.Name]; ok {
+		return string(patch)
+	}
+	return manifest
+}
+
+func appendManifest(existing, manifest string) string {
+	if existing == "" {
+		return manifest
+	}
+	return existing + "\n---\n" + manifest
+}
@@ -0,0 +1,195 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"archive/tar"
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// profileOverlayFromChart collects the "profile/"-prefixed chart.Files
+// entries withProfileOverlay stashed on chrt and repacks them as a
+// ProfileOverlay archive for appName, the way a real deployment-intent
+// archive would be laid out on disk ("<appName>/...").
+func profileOverlayFromChart(t *testing.T, chrt *chart.Chart, appName string) *ProfileOverlay {
+	t.Helper()
+
+	files := map[string]string{}
+	for _, f := range chrt.Files {
+		if rel := strings.TrimPrefix(f.Name, "profile/"); rel != f.Name {
+			files[path.Join(appName, rel)] = string(f.Data)
+		}
+	}
+	return newProfileOverlay(t, files)
+}
+
+func newProfileOverlay(t *testing.T, files map[string]string) *ProfileOverlay {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay, err := LoadProfileOverlay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return overlay
+}
+
+func TestRenderCompositeAppliesProfileOverlay(t *testing.T) {
+	cfg := actionConfigFixture(t)
+
+	frontend := buildChart(withName("frontend"))
+	apps := []*CompositeApp{
+		{Name: "frontend", Chart: frontend, Values: map[string]interface{}{}},
+	}
+
+	overlay := newProfileOverlay(t, map[string]string{
+		"frontend/templates/extra.yaml": "kind: ConfigMap\nmetadata:\n  name: extra\n",
+	})
+
+	rc := NewRenderComposite(cfg)
+	out, err := rc.Run(apps, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "name: extra") {
+		t.Errorf("expected profile overlay manifest in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "hello: world") {
+		t.Errorf("expected base chart manifest in output, got:\n%s", out)
+	}
+}
+
+func TestRenderCompositeValuesOverride(t *testing.T) {
+	overlay := newProfileOverlay(t, map[string]string{
+		"frontend/values.yaml": "someKey: overridden\n",
+	})
+
+	vals, ok, err := overlay.valuesOverride("frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected a values override to be found")
+	}
+	if vals["someKey"] != "overridden" {
+		t.Errorf("expected overridden value, got %v", vals["someKey"])
+	}
+}
+
+// TestRenderCompositeValuesAreMergedNotReplaced guards against a profile
+// overlay silently dropping every base value it doesn't itself mention: the
+// overlay should win for keys it sets, but untouched base keys must survive.
+// This exercises the same chartutil.CoalesceTables call RenderComposite.Run
+// makes when it finds a values.yaml override.
+func TestRenderCompositeValuesAreMergedNotReplaced(t *testing.T) {
+	base := map[string]interface{}{
+		"someKey":   "base",
+		"untouched": "keepme",
+	}
+	overlay := newProfileOverlay(t, map[string]string{
+		"frontend/values.yaml": "someKey: overridden\n",
+	})
+
+	override, ok, err := overlay.valuesOverride("frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected a values override to be found")
+	}
+
+	merged := chartutil.CoalesceTables(override, base)
+	if merged["someKey"] != "overridden" {
+		t.Errorf("expected overlay to win for someKey, got %v", merged["someKey"])
+	}
+	if merged["untouched"] != "keepme" {
+		t.Errorf("expected untouched base value to survive the merge, got %v", merged["untouched"])
+	}
+}
+
+// TestRenderCompositeRunMergesProfileOverlayValues covers the same
+// merge-not-replace guarantee as TestRenderCompositeValuesAreMergedNotReplaced,
+// but end-to-end through RenderComposite.Run rather than by calling
+// chartutil.CoalesceTables directly, so a regression in how Run wires the
+// override into the render would actually fail this test.
+func TestRenderCompositeRunMergesProfileOverlayValues(t *testing.T) {
+	cfg := actionConfigFixture(t)
+
+	configMapTemplate := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  someKey: {{ .Values.someKey | quote }}
+  untouched: {{ .Values.untouched | quote }}
+`
+	frontend := buildChart(
+		withName("frontend"),
+		withProfileOverlay(map[string]string{"values.yaml": "someKey: overridden\n"}),
+	)
+	frontend.Templates = append(frontend.Templates, &chart.File{
+		Name: "templates/configmap.yaml",
+		Data: []byte(configMapTemplate),
+	})
+
+	overlay := profileOverlayFromChart(t, frontend, "frontend")
+
+	apps := []*CompositeApp{
+		{
+			Name:  "frontend",
+			Chart: frontend,
+			Values: map[string]interface{}{
+				"someKey":   "base",
+				"untouched": "keepme",
+			},
+		},
+	}
+
+	rc := NewRenderComposite(cfg)
+	out, err := rc.Run(apps, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, `someKey: "overridden"`) {
+		t.Errorf("expected overlay to win for someKey in rendered output, got:\n%s", out)
+	}
+	if !strings.Contains(out, `untouched: "keepme"`) {
+		t.Errorf("expected untouched base value to survive in rendered output, got:\n%s", out)
+	}
+}
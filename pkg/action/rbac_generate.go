@@ -0,0 +1,243 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// rbacVerbs are the verbs granted for every resource kind a chart's rendered
+// manifests touch. Helm (or a caller embedding it, such as an operator) needs
+// the same access it would need to run install/upgrade/uninstall by hand.
+var rbacVerbs = []string{"create", "get", "list", "watch", "update", "patch", "delete"}
+
+// crdGroupResource is the resource Helm itself must be allowed to manage in
+// order to install the CustomResourceDefinitions bundled in a chart's crds/
+// directory, independent of whatever custom resources those CRDs describe.
+var crdGroupResource = schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+
+// RBACGen derives the minimum RBAC Role/ClusterRole needed to install a
+// chart's rendered manifests, for use by downstream operators that embed
+// Helm as a library.
+type RBACGen struct {
+	cfg *Configuration
+
+	// ReleaseName and Namespace seed the same template context Install uses,
+	// so that any {{ .Release.* }} references in the chart resolve the same
+	// way they would during a real install.
+	ReleaseName string
+	Namespace   string
+}
+
+// NewRBACGen creates a new RBACGen action.
+func NewRBACGen(cfg *Configuration) *RBACGen {
+	return &RBACGen{cfg: cfg}
+}
+
+// Run renders chrt with vals, walks every resulting object, and returns YAML
+// for a Role (namespaced kinds) and a ClusterRole (cluster-scoped kinds)
+// granting the access required to manage them.
+func (r *RBACGen) Run(chrt *chart.Chart, vals map[string]interface{}) (string, error) {
+	caps := r.cfg.Capabilities
+	if caps == nil {
+		caps = chartutil.DefaultCapabilities
+	}
+
+	options := chartutil.ReleaseOptions{
+		Name:      r.ReleaseName,
+		Namespace: r.Namespace,
+	}
+
+	valuesToRender, err := chartutil.ToRenderValues(chrt, vals, options, caps)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render values")
+	}
+
+	rendered, err := engine.Render(chrt, valuesToRender)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render chart templates")
+	}
+
+	clientset, err := r.cfg.KubernetesClientSet()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create kubernetes client")
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(clientset.Discovery())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch discovery information")
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	namespaced := map[schema.GroupResource]bool{}
+	clusterScoped := map[schema.GroupResource]bool{}
+
+	for name, content := range rendered {
+		if strings.HasSuffix(name, notesFileSuffix) {
+			continue
+		}
+		if strings.HasPrefix(path.Base(name), "_") {
+			continue
+		}
+
+		for _, manifest := range releaseutil.SplitManifests(content) {
+			if err := addManifestRule(manifest, mapper, namespaced, clusterScoped); err != nil {
+				return "", errors.Wrapf(err, "failed to process %s", name)
+			}
+		}
+	}
+
+	addCRDRules(chrt, clusterScoped)
+
+	roleName := r.ReleaseName
+	if roleName == "" {
+		roleName = "helm-release"
+	}
+	roleName = fmt.Sprintf("%s-role", roleName)
+
+	var docs []string
+	if rules := rulesFor(namespaced); len(rules) > 0 {
+		role := &rbacv1.Role{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Role",
+				APIVersion: "rbac.authorization.k8s.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      roleName,
+				Namespace: r.Namespace,
+			},
+			Rules: rules,
+		}
+		doc, err := yaml.Marshal(role)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal Role")
+		}
+		docs = append(docs, string(doc))
+	}
+
+	if rules := rulesFor(clusterScoped); len(rules) > 0 {
+		clusterRole := &rbacv1.ClusterRole{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ClusterRole",
+				APIVersion: "rbac.authorization.k8s.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: roleName,
+			},
+			Rules: rules,
+		}
+		doc, err := yaml.Marshal(clusterRole)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal ClusterRole")
+		}
+		docs = append(docs, string(doc))
+	}
+
+	return strings.Join(docs, "---\n"), nil
+}
+
+// addManifestRule parses a single rendered manifest document and records the
+// GroupResource it needs access to, bucketed by whether the kind is
+// namespaced or cluster-scoped.
+func addManifestRule(manifest string, mapper meta.RESTMapper, namespaced, clusterScoped map[schema.GroupResource]bool) error {
+	manifest = strings.TrimSpace(manifest)
+	if manifest == "" {
+		return nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &obj.Object); err != nil {
+		return errors.Wrap(err, "failed to unmarshal manifest")
+	}
+	if obj.GetKind() == "" {
+		return nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to map %s", gvk)
+	}
+
+	gr := mapping.Resource.GroupResource()
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespaced[gr] = true
+	} else {
+		clusterScoped[gr] = true
+	}
+	return nil
+}
+
+// addCRDRules grants access to the CustomResourceDefinition resource itself
+// whenever chrt vendors any CRDs under crds/, since Helm must be able to
+// install them before any object of the kind they define can exist.
+func addCRDRules(chrt *chart.Chart, clusterScoped map[schema.GroupResource]bool) {
+	for _, crd := range chrt.CRDObjects() {
+		for _, manifest := range releaseutil.SplitManifests(string(crd.File.Data)) {
+			if strings.TrimSpace(manifest) == "" {
+				continue
+			}
+			clusterScoped[crdGroupResource] = true
+		}
+	}
+}
+
+func rulesFor(resources map[schema.GroupResource]bool) []rbacv1.PolicyRule {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	byGroup := map[string][]string{}
+	for gr := range resources {
+		byGroup[gr.Group] = append(byGroup[gr.Group], gr.Resource)
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for g := range byGroup {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	rules := make([]rbacv1.PolicyRule, 0, len(groups))
+	for _, g := range groups {
+		resources := byGroup[g]
+		sort.Strings(resources)
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{g},
+			Resources: resources,
+			Verbs:     rbacVerbs,
+		})
+	}
+	return rules
+}
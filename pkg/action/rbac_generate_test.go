@@ -0,0 +1,165 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// fakeRESTMapper maps a fixed set of GroupKinds to RESTMappings, so
+// addManifestRule can be tested without a live cluster's discovery client.
+type fakeRESTMapper struct {
+	meta.RESTMapper
+
+	mappings map[schema.GroupKind]*meta.RESTMapping
+}
+
+func (f *fakeRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	if m, ok := f.mappings[gk]; ok {
+		return m, nil
+	}
+	return nil, errors.Errorf("no mapping for %s", gk)
+}
+
+func namespacedMapping(group, resource, kind string) *meta.RESTMapping {
+	return &meta.RESTMapping{
+		Resource:         schema.GroupVersionResource{Group: group, Resource: resource},
+		GroupVersionKind: schema.GroupVersionKind{Group: group, Kind: kind},
+		Scope:            meta.RESTScopeNamespace,
+	}
+}
+
+func clusterScopedMapping(group, resource, kind string) *meta.RESTMapping {
+	return &meta.RESTMapping{
+		Resource:         schema.GroupVersionResource{Group: group, Resource: resource},
+		GroupVersionKind: schema.GroupVersionKind{Group: group, Kind: kind},
+		Scope:            meta.RESTScopeRoot,
+	}
+}
+
+func TestAddManifestRuleBucketsByScope(t *testing.T) {
+	mapper := &fakeRESTMapper{
+		mappings: map[schema.GroupKind]*meta.RESTMapping{
+			{Kind: "ConfigMap"}: namespacedMapping("", "configmaps", "ConfigMap"),
+			{Kind: "Namespace"}: clusterScopedMapping("", "namespaces", "Namespace"),
+		},
+	}
+
+	namespaced := map[schema.GroupResource]bool{}
+	clusterScoped := map[schema.GroupResource]bool{}
+
+	manifests := []string{
+		"kind: ConfigMap\nmetadata:\n  name: cm\n",
+		"kind: Namespace\nmetadata:\n  name: ns\n",
+		"", // blank documents (from a trailing "---") must be ignored
+	}
+	for _, manifest := range manifests {
+		if err := addManifestRule(manifest, mapper, namespaced, clusterScoped); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !namespaced[schema.GroupResource{Resource: "configmaps"}] {
+		t.Errorf("expected configmaps to be bucketed as namespaced, got %v", namespaced)
+	}
+	if !clusterScoped[schema.GroupResource{Resource: "namespaces"}] {
+		t.Errorf("expected namespaces to be bucketed as cluster-scoped, got %v", clusterScoped)
+	}
+	if len(namespaced) != 1 || len(clusterScoped) != 1 {
+		t.Errorf("expected exactly one entry per bucket, got namespaced=%v clusterScoped=%v", namespaced, clusterScoped)
+	}
+}
+
+func TestAddManifestRulePropagatesMapperError(t *testing.T) {
+	mapper := &fakeRESTMapper{mappings: map[schema.GroupKind]*meta.RESTMapping{}}
+
+	err := addManifestRule("kind: Widget\nmetadata:\n  name: w\n", mapper,
+		map[schema.GroupResource]bool{}, map[schema.GroupResource]bool{})
+	if err == nil {
+		t.Fatal("expected an error for an unmappable kind")
+	}
+}
+
+func TestRulesForAggregatesByGroupAndDedupes(t *testing.T) {
+	resources := map[schema.GroupResource]bool{
+		{Group: "", Resource: "configmaps"}:       true,
+		{Group: "", Resource: "secrets"}:          true,
+		{Group: "apps", Resource: "deployments"}: true,
+	}
+
+	rules := rulesFor(resources)
+	if len(rules) != 2 {
+		t.Fatalf("expected one rule per API group, got %d: %+v", len(rules), rules)
+	}
+
+	byGroup := map[string][]string{}
+	for _, rule := range rules {
+		byGroup[rule.APIGroups[0]] = rule.Resources
+	}
+
+	if got := byGroup[""]; len(got) != 2 || got[0] != "configmaps" || got[1] != "secrets" {
+		t.Errorf("expected sorted [configmaps secrets] for the core group, got %v", got)
+	}
+	if got := byGroup["apps"]; len(got) != 1 || got[0] != "deployments" {
+		t.Errorf("expected [deployments] for the apps group, got %v", got)
+	}
+
+	for _, rule := range rules {
+		if len(rule.Verbs) != len(rbacVerbs) {
+			t.Errorf("expected every rule to carry the full verb set, got %v", rule.Verbs)
+		}
+	}
+}
+
+func TestRulesForEmpty(t *testing.T) {
+	if rules := rulesFor(map[schema.GroupResource]bool{}); rules != nil {
+		t.Errorf("expected no rules for an empty resource set, got %+v", rules)
+	}
+}
+
+func TestAddCRDRulesGrantsCustomResourceDefinitions(t *testing.T) {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "hascrds", Version: "0.1.0"},
+		Raw: []*chart.File{
+			{Name: "crds/widgets.example.com.yaml", Data: []byte("kind: CustomResourceDefinition\n")},
+		},
+	}
+
+	clusterScoped := map[schema.GroupResource]bool{}
+	addCRDRules(chrt, clusterScoped)
+
+	if !clusterScoped[crdGroupResource] {
+		t.Errorf("expected %+v to be granted for a chart with crds/, got %v", crdGroupResource, clusterScoped)
+	}
+}
+
+func TestAddCRDRulesNoCRDs(t *testing.T) {
+	chrt := buildChart()
+
+	clusterScoped := map[schema.GroupResource]bool{}
+	addCRDRules(chrt, clusterScoped)
+
+	if len(clusterScoped) != 0 {
+		t.Errorf("expected no rules for a chart without crds/, got %v", clusterScoped)
+	}
+}
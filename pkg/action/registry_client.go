@@ -0,0 +1,66 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+
+	dockerauth "github.com/deislabs/oras/pkg/auth/docker"
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/internal/experimental/registry"
+)
+
+// NewRegistryClient builds the registry.Client a Configuration should use for
+// OCI operations. With no providers, it authenticates the same way the CLI
+// always has, via dockerauth.NewClient() and the on-disk Docker config. Given
+// one or more registry.CredentialProviders, it instead chains them as the
+// resolver's credential source, so callers embedding Helm can authenticate
+// against OCI registries using Kubernetes imagePullSecrets, a cloud
+// provider's token helper, or any other CredentialProvider, without a Docker
+// config file on disk at all.
+func NewRegistryClient(cache *registry.Cache, providers ...registry.CredentialProvider) (*registry.Client, error) {
+	if len(providers) > 0 {
+		resolver := registry.NewResolverFromCredentialProvider(&registry.ChainedCredentialProvider{Providers: providers})
+		return registry.NewClient(
+			registry.ClientOptResolver(&registry.Resolver{
+				Resolver: resolver,
+			}),
+			registry.ClientOptCache(cache),
+		)
+	}
+
+	client, err := dockerauth.NewClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create docker auth client")
+	}
+
+	resolver, err := client.Resolver(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create docker resolver")
+	}
+
+	return registry.NewClient(
+		registry.ClientOptAuthorizer(&registry.Authorizer{
+			Client: client,
+		}),
+		registry.ClientOptResolver(&registry.Resolver{
+			Resolver: resolver,
+		}),
+		registry.ClientOptCache(cache),
+	)
+}
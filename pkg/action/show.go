@@ -0,0 +1,136 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/internal/experimental/registry"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// ShowOutputFormat is the format of the output of `helm show`.
+type ShowOutputFormat string
+
+const (
+	// ShowAll is the format which shows all the information.
+	ShowAll ShowOutputFormat = "all"
+	// ShowChart is the format which only shows the chart's definition.
+	ShowChart ShowOutputFormat = "chart"
+	// ShowValues is the format which only shows the chart's values.
+	ShowValues ShowOutputFormat = "values"
+	// ShowReadme is the format which only shows the chart's readme.
+	ShowReadme ShowOutputFormat = "readme"
+)
+
+// Show is the action for inspecting a chart's metadata, values, or readme.
+type Show struct {
+	cfg *Configuration
+
+	Output ShowOutputFormat
+}
+
+// NewShow creates a new Show action.
+func NewShow(cfg *Configuration, output ShowOutputFormat) *Show {
+	return &Show{cfg: cfg, Output: output}
+}
+
+// Run returns the s.Output piece of the chart at chartRef.
+//
+// For an oci:// chartRef, ShowValues and ShowReadme are served straight out
+// of the registry's addition API (internal/experimental/registry), so they
+// never pull or unpack the full chart tarball. loadFull, supplied by the
+// caller (which already knows how to locate and load a chart from any
+// source), is used instead whenever the addition fetch doesn't apply:
+// ShowChart/ShowAll always need the full chart, and a non-OCI chartRef has
+// no addition API to call.
+func (s *Show) Run(chartRef string, loadFull func() (*chart.Chart, error)) (string, error) {
+	if additionType, ok := additionTypeFor(s.Output); ok {
+		content, handled, err := s.cfg.chartAddition(chartRef, additionType)
+		if err != nil {
+			return "", err
+		}
+		if handled {
+			return content, nil
+		}
+	}
+
+	chrt, err := loadFull()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load chart %s", chartRef)
+	}
+	return renderShow(chrt, s.Output)
+}
+
+// additionTypeFor reports the registry.AdditionType that can serve output on
+// its own, without loading the rest of the chart.
+func additionTypeFor(output ShowOutputFormat) (registry.AdditionType, bool) {
+	switch output {
+	case ShowValues:
+		return registry.AdditionValues, true
+	case ShowReadme:
+		return registry.AdditionReadme, true
+	default:
+		return "", false
+	}
+}
+
+// renderShow builds the output for output out of an already-loaded chart.
+func renderShow(chrt *chart.Chart, output ShowOutputFormat) (string, error) {
+	var out strings.Builder
+
+	if output == ShowChart || output == ShowAll {
+		metadata, err := yaml.Marshal(chrt.Metadata)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal chart metadata")
+		}
+		fmt.Fprintf(&out, "%s\n", metadata)
+	}
+
+	if (output == ShowValues || output == ShowAll) && len(chrt.Values) > 0 {
+		values, err := yaml.Marshal(chrt.Values)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal chart values")
+		}
+		fmt.Fprintf(&out, "%s\n", values)
+	}
+
+	if output == ShowReadme || output == ShowAll {
+		if readme := findReadme(chrt.Files); readme != "" {
+			fmt.Fprintln(&out, readme)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// findReadme returns the content of the first file in files that looks like
+// a chart readme.
+func findReadme(files []*chart.File) string {
+	for _, f := range files {
+		for _, name := range []string{"readme.md", "readme.txt", "readme"} {
+			if strings.EqualFold(f.Name, name) {
+				return string(f.Data)
+			}
+		}
+	}
+	return ""
+}
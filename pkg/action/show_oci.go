@@ -0,0 +1,51 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/internal/experimental/registry"
+)
+
+// ociScheme is the prefix identifying a chart reference as an OCI registry
+// reference rather than a repository/local path.
+const ociScheme = "oci://"
+
+// chartAddition fetches a single piece of an OCI chart (its values.yaml,
+// README.md, or dependency tree) without pulling and unpacking the full
+// chart tarball. Show.Run calls this for ShowValues and ShowReadme only;
+// ShowChart and ShowAll always load the full chart. A chartRef that does not
+// use the oci:// scheme is reported as not handled, and the caller falls back
+// to a full load.
+func (cfg *Configuration) chartAddition(chartRef string, additionType registry.AdditionType) (string, bool, error) {
+	if !strings.HasPrefix(chartRef, ociScheme) {
+		return "", false, nil
+	}
+	if cfg.RegistryClient == nil {
+		return "", false, errors.New("OCI chart reference given but no registry client configured")
+	}
+
+	ref := strings.TrimPrefix(chartRef, ociScheme)
+	content, err := cfg.RegistryClient.GetAddition(ref, additionType)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to fetch %s for %s", additionType, chartRef)
+	}
+	return content, true, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestShowRunFallsBackToFullLoadForNonOCIRef(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	s := NewShow(cfg, ShowValues)
+
+	chrt := buildChart(withValues(map[string]interface{}{"someKey": "someValue"}))
+	var loadFullCalled bool
+	out, err := s.Run("mychart", func() (*chart.Chart, error) {
+		loadFullCalled = true
+		return chrt, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loadFullCalled {
+		t.Error("expected loadFull to be used for a non-oci:// chartRef")
+	}
+	if out == "" {
+		t.Error("expected non-empty values output")
+	}
+}
+
+func TestShowRunAlwaysFullLoadsForShowChartAndShowAll(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	chrt := buildChart()
+
+	for _, output := range []ShowOutputFormat{ShowChart, ShowAll} {
+		var loadFullCalled bool
+		s := NewShow(cfg, output)
+		if _, err := s.Run("oci://example.com/mychart", func() (*chart.Chart, error) {
+			loadFullCalled = true
+			return chrt, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !loadFullCalled {
+			t.Errorf("expected loadFull to be used for Output=%s even with an oci:// ref", output)
+		}
+	}
+}
+
+func TestAdditionTypeFor(t *testing.T) {
+	cases := []struct {
+		output ShowOutputFormat
+		ok     bool
+	}{
+		{ShowValues, true},
+		{ShowReadme, true},
+		{ShowChart, false},
+		{ShowAll, false},
+	}
+	for _, c := range cases {
+		_, ok := additionTypeFor(c.output)
+		if ok != c.ok {
+			t.Errorf("additionTypeFor(%s) ok = %v, want %v", c.output, ok, c.ok)
+		}
+	}
+}